@@ -0,0 +1,67 @@
+package filestorage
+
+import (
+	"context"
+	"io"
+
+	"github.com/lovego/errs"
+)
+
+var errURLNotSupported = errs.New("args-err", "this backend does not support URL")
+
+// Backend stores and retrieves file content by key. Bucket's linking and
+// dedup logic is backend-agnostic; it only ever deals in hashes, which
+// FilePath turns into backend keys.
+type Backend interface {
+	// Put stores the content read from r (of the given size) under key.
+	// Implementations should be idempotent: if key already exists, Put
+	// may skip the write instead of erroring.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get returns a reader for the content stored under key, along with
+	// its size.
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	// GetRange returns a reader for the length bytes of key starting at
+	// off, using the backend's own ranged read (an S3/Azure/GCS Range
+	// request, a Seek on local disk) rather than a full Get with the
+	// prefix discarded.
+	GetRange(ctx context.Context, key string, off, length int64) (io.ReadCloser, error)
+	// Stat returns the size of the content stored under key, without
+	// fetching its content; parseRange uses this to size an incoming
+	// Range request instead of a full Get.
+	Stat(ctx context.Context, key string) (int64, error)
+	// Delete removes the content stored under key.
+	Delete(ctx context.Context, key string) error
+	// URL returns a backend-specific direct URL for key, if the backend
+	// supports one. Backends that don't should return errURLNotSupported.
+	URL(key string) (string, error)
+}
+
+// backend returns b.Backend, defaulting it to a LocalBackend built from
+// Dir/DirDepth/Machines/ScpUser so existing configs keep working unchanged.
+func (b *Bucket) backend() (Backend, error) {
+	if b.Backend != nil {
+		return b.Backend, nil
+	}
+	local, err := NewLocalBackend(b.Dir, b.DirDepth, b.Machines, b.ScpUser)
+	if err != nil {
+		return nil, err
+	}
+	b.Backend = local
+	return b.Backend, nil
+}
+
+// BackendURL returns the backend's own direct URL for hash (e.g. an S3
+// object URL), if it supports one; see errURLNotSupported. This is
+// distinct from PresignGet: it's a (possibly public, possibly requiring
+// the backend's own auth) URL the backend always exposes, not a
+// time-limited signed URL this package generates.
+func (b *Bucket) BackendURL(hash string) (string, error) {
+	if err := CheckHash(hash); err != nil {
+		return "", err
+	}
+	backend, err := b.backend()
+	if err != nil {
+		return "", err
+	}
+	return backend.URL(b.FilePath(hash))
+}
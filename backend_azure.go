@@ -0,0 +1,70 @@
+package filestorage
+
+import (
+	"context"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// AzureBackend stores files in an Azure Blob Storage container, keyed by
+// Prefix+key.
+type AzureBackend struct {
+	Client    *azblob.Client
+	Container string
+	Prefix    string
+}
+
+func (b *AzureBackend) key(key string) string {
+	return b.Prefix + key
+}
+
+func (b *AzureBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := b.Client.UploadStream(ctx, b.Container, b.key(key), r, nil)
+	return err
+}
+
+func (b *AzureBackend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	out, err := b.Client.DownloadStream(ctx, b.Container, b.key(key), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	var size int64
+	if out.ContentLength != nil {
+		size = *out.ContentLength
+	}
+	return out.Body, size, nil
+}
+
+func (b *AzureBackend) GetRange(ctx context.Context, key string, off, length int64) (io.ReadCloser, error) {
+	out, err := b.Client.DownloadStream(ctx, b.Container, b.key(key), &azblob.DownloadStreamOptions{
+		Range: azblob.HTTPRange{Offset: off, Count: length},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (b *AzureBackend) Stat(ctx context.Context, key string) (int64, error) {
+	props, err := b.Client.ServiceClient().NewContainerClient(b.Container).
+		NewBlobClient(b.key(key)).GetProperties(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	if props.ContentLength != nil {
+		size = *props.ContentLength
+	}
+	return size, nil
+}
+
+func (b *AzureBackend) Delete(ctx context.Context, key string) error {
+	_, err := b.Client.DeleteBlob(ctx, b.Container, b.key(key), nil)
+	return err
+}
+
+func (b *AzureBackend) URL(key string) (string, error) {
+	return b.Client.ServiceClient().NewContainerClient(b.Container).
+		NewBlobClient(b.key(key)).URL(), nil
+}
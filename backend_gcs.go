@@ -0,0 +1,58 @@
+package filestorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+)
+
+// GCSBackend stores files in a Google Cloud Storage bucket, keyed by
+// Prefix+key.
+type GCSBackend struct {
+	Client *storage.Client
+	Bucket string
+	Prefix string
+}
+
+func (b *GCSBackend) object(key string) *storage.ObjectHandle {
+	return b.Client.Bucket(b.Bucket).Object(b.Prefix + key)
+}
+
+func (b *GCSBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	w := b.object(key).NewWriter(ctx)
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (b *GCSBackend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	r, err := b.object(key).NewReader(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, r.Attrs.Size, nil
+}
+
+func (b *GCSBackend) GetRange(ctx context.Context, key string, off, length int64) (io.ReadCloser, error) {
+	return b.object(key).NewRangeReader(ctx, off, length)
+}
+
+func (b *GCSBackend) Stat(ctx context.Context, key string) (int64, error) {
+	attrs, err := b.object(key).Attrs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return attrs.Size, nil
+}
+
+func (b *GCSBackend) Delete(ctx context.Context, key string) error {
+	return b.object(key).Delete(ctx)
+}
+
+func (b *GCSBackend) URL(key string) (string, error) {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", b.Bucket, b.Prefix+key), nil
+}
@@ -0,0 +1,164 @@
+package filestorage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/lovego/addrs"
+)
+
+// LocalBackend stores files under a local or mounted directory and mirrors
+// them to other machines via scp, which is the original (pre-Backend)
+// behavior of this package.
+type LocalBackend struct {
+	Dir      string
+	DirDepth uint8
+	Machines []string
+	ScpUser  string
+
+	localMachine  bool
+	otherMachines []string
+}
+
+// NewLocalBackend builds a LocalBackend, resolving which of Machines is the
+// local one.
+func NewLocalBackend(dir string, dirDepth uint8, machines []string, scpUser string) (*LocalBackend, error) {
+	b := &LocalBackend{Dir: dir, DirDepth: dirDepth, Machines: machines, ScpUser: scpUser}
+	if err := b.parseMachines(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *LocalBackend) parseMachines() error {
+	var user string
+	if b.ScpUser != "" {
+		user = b.ScpUser + "@"
+	}
+	for _, addr := range b.Machines {
+		if ok, err := addrs.IsLocalhost(addr); err != nil {
+			return err
+		} else if ok {
+			b.localMachine = true
+		} else {
+			b.otherMachines = append(b.otherMachines, user+addr)
+		}
+	}
+	return nil
+}
+
+func (b *LocalBackend) path(key string) string {
+	return filepath.Join(b.Dir, key)
+}
+
+func (b *LocalBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	destPath := b.path(key)
+	var srcPath string
+	if b.localMachine || len(b.Machines) == 0 {
+		if err := writeFile(r, destPath); err != nil {
+			return err
+		}
+		srcPath = destPath
+	} else {
+		tempFile, err := writeTempFile(r)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tempFile)
+		srcPath = tempFile
+	}
+	for _, addr := range b.otherMachines {
+		if err := exec.Command("scp", srcPath, addr+":"+destPath).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *LocalBackend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	f, err := os.Open(b.path(key))
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func (b *LocalBackend) GetRange(ctx context.Context, key string, off, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(b.path(key))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return readCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+func (b *LocalBackend) Stat(ctx context.Context, key string) (int64, error) {
+	info, err := os.Stat(b.path(key))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	destPath := b.path(key)
+	if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, addr := range b.otherMachines {
+		if err := exec.Command("ssh", addr, "rm", "-f", destPath).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *LocalBackend) URL(key string) (string, error) {
+	return "", errURLNotSupported
+}
+
+func writeFile(file io.Reader, destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer destFile.Close()
+	_, err = io.Copy(destFile, file)
+	return err
+}
+
+func writeTempFile(file io.Reader) (string, error) {
+	temp, err := ioutil.TempFile("", "fs_")
+	if err != nil {
+		return "", err
+	}
+	defer temp.Close()
+	if _, err := io.Copy(temp, file); err != nil {
+		return "", err
+	}
+	return temp.Name(), nil
+}
@@ -0,0 +1,93 @@
+package filestorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Backend stores files in an S3-compatible bucket, keyed by Prefix+key.
+type S3Backend struct {
+	Client *s3.Client
+	Bucket string
+	Prefix string
+	// Region is the bucket's AWS region, e.g. "us-west-2"; URL uses it to
+	// build a region-correct endpoint. Leave empty for us-east-1.
+	Region string
+	// Endpoint, if set, overrides URL entirely — for S3-compatible stores
+	// (MinIO, etc.) rather than AWS S3 itself.
+	Endpoint string
+}
+
+func (b *S3Backend) key(key string) string {
+	return b.Prefix + key
+}
+
+func (b *S3Backend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := b.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(b.Bucket),
+		Key:           aws.String(b.key(key)),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+	})
+	return err
+}
+
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	out, err := b.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(b.key(key)),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out.Body, aws.ToInt64(out.ContentLength), nil
+}
+
+func (b *S3Backend) GetRange(ctx context.Context, key string, off, length int64) (io.ReadCloser, error) {
+	out, err := b.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(b.key(key)),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+length-1)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (b *S3Backend) Stat(ctx context.Context, key string) (int64, error) {
+	out, err := b.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(b.key(key)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return aws.ToInt64(out.ContentLength), nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	_, err := b.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(b.key(key)),
+	})
+	return err
+}
+
+// URL derives a direct object URL from the backend's own Endpoint/Region
+// config rather than assuming AWS S3's default (us-east-1) endpoint, so it
+// also works for other regions and S3-compatible stores like MinIO.
+func (b *S3Backend) URL(key string) (string, error) {
+	if b.Endpoint != "" {
+		return strings.TrimRight(b.Endpoint, "/") + "/" + b.Bucket + "/" + b.key(key), nil
+	}
+	if b.Region != "" && b.Region != "us-east-1" {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", b.Bucket, b.Region, b.key(key)), nil
+	}
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", b.Bucket, b.key(key)), nil
+}
@@ -0,0 +1,40 @@
+package filestorage
+
+import "testing"
+
+func TestParseByteRange(t *testing.T) {
+	const size = int64(100)
+
+	cases := []struct {
+		header      string
+		off, length int64
+		wantErr     bool
+	}{
+		{header: "bytes=0-49", off: 0, length: 50},
+		{header: "bytes=50-99", off: 50, length: 50},
+		{header: "bytes=50-", off: 50, length: 50},
+		{header: "bytes=-10", off: 90, length: 10},
+		{header: "bytes=-1000", off: 0, length: 100},
+		{header: "bytes=0-1000", off: 0, length: 100},
+		{header: "bytes=50-10", wantErr: true},
+		{header: "bytes=abc-10", wantErr: true},
+		{header: "bytes=0", wantErr: true},
+	}
+
+	for _, c := range cases {
+		off, length, err := parseByteRange(c.header, size)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseByteRange(%q): expected error, got off=%d length=%d", c.header, off, length)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseByteRange(%q): unexpected error: %v", c.header, err)
+			continue
+		}
+		if off != c.off || length != c.length {
+			t.Errorf("parseByteRange(%q) = (%d, %d), want (%d, %d)", c.header, off, length, c.off, c.length)
+		}
+	}
+}
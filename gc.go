@@ -0,0 +1,119 @@
+package filestorage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultGCSafetyWindow = 24 * time.Hour
+
+func (b *Bucket) safetyWindow() time.Duration {
+	if b.GCSafetyWindow > 0 {
+		return b.GCSafetyWindow
+	}
+	return defaultGCSafetyWindow
+}
+
+// GC finds files with zero links whose files-table row is older than
+// olderThan (floored to the bucket's safety window, or a 24h default so GC
+// never races with an in-flight upload that has inserted the file record
+// but not yet linked it) and deletes both the row and the backend blob. It
+// returns the hashes removed. Because files are content-addressed and
+// deduplicated across every object that links them, this is what keeps
+// storage bounded once Unlink/UnlinkAllOf stop doing so on their own.
+func (b *Bucket) GC(db DB, olderThan time.Duration) ([]string, error) {
+	return b.gc(db, olderThan, false)
+}
+
+// GCDryRun reports which hashes GC would remove, without deleting anything.
+func (b *Bucket) GCDryRun(db DB, olderThan time.Duration) ([]string, error) {
+	return b.gc(db, olderThan, true)
+}
+
+func (b *Bucket) gc(db DB, olderThan time.Duration, dryRun bool) ([]string, error) {
+	safety := b.safetyWindow()
+	if olderThan < safety {
+		olderThan = safety
+	}
+	hashes, err := b.orphanedOlderThan(db, time.Now().Add(-olderThan), nil)
+	if err != nil {
+		return nil, err
+	}
+	return b.gcHashes(db, hashes, dryRun)
+}
+
+// orphanedOlderThan returns the files-table hashes, from among of (or all,
+// if of is nil), with zero links and a created_at before cutoff. This is
+// the query both GC and AutoGC use, so AutoGC can never delete a blob
+// younger than the safety window out from under a concurrent upload that
+// re-links it.
+func (b *Bucket) orphanedOlderThan(db DB, cutoff time.Time, of []string) ([]string, error) {
+	sql := fmt.Sprintf(`
+	SELECT f.hash FROM %s f
+	WHERE f.created_at < %s AND NOT EXISTS (SELECT 1 FROM %s l WHERE l.file = f.hash)
+	`, b.FilesTable, fmtTime(cutoff), b.LinksTable,
+	)
+	if of != nil {
+		quoted := make([]string, len(of))
+		for i, hash := range of {
+			quoted[i] = quote(hash)
+		}
+		sql += fmt.Sprintf(" AND f.hash IN (%s)", strings.Join(quoted, ", "))
+	}
+
+	rows, err := b.getDB(db).Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// autoGCHashes GC's whichever of hashes are still orphaned and past the
+// safety window, for Unlink/UnlinkAllOf's AutoGC option.
+func (b *Bucket) autoGCHashes(db DB, hashes []string) ([]string, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	eligible, err := b.orphanedOlderThan(db, time.Now().Add(-b.safetyWindow()), hashes)
+	if err != nil {
+		return nil, err
+	}
+	return b.gcHashes(db, eligible, false)
+}
+
+// gcHashes deletes each hash's backend blob and files-table row, unless
+// dryRun is set, in which case it just reports what would be removed.
+func (b *Bucket) gcHashes(db DB, hashes []string, dryRun bool) ([]string, error) {
+	if len(hashes) == 0 || dryRun {
+		return hashes, nil
+	}
+	backend, err := b.backend()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, hash := range hashes {
+		if err := backend.Delete(context.Background(), b.FilePath(hash)); err != nil {
+			return removed, err
+		}
+		if _, err := b.getDB(db).Exec(fmt.Sprintf(
+			`DELETE FROM %s WHERE hash = %s`, b.FilesTable, quote(hash),
+		)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, hash)
+	}
+	return removed, nil
+}
@@ -0,0 +1,21 @@
+package filestorage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSafetyWindowDefault(t *testing.T) {
+	b := &Bucket{}
+	if got := b.safetyWindow(); got != defaultGCSafetyWindow {
+		t.Fatalf("safetyWindow() = %v, want default %v", got, defaultGCSafetyWindow)
+	}
+}
+
+func TestSafetyWindowOverride(t *testing.T) {
+	want := 2 * time.Hour
+	b := &Bucket{GCSafetyWindow: want}
+	if got := b.safetyWindow(); got != want {
+		t.Fatalf("safetyWindow() = %v, want configured %v", got, want)
+	}
+}
@@ -85,15 +85,26 @@ func (b *Bucket) LinkOnly(db DB, object string, files ...string) error {
 	})
 }
 
-// UnlinkAllOf unlink all linked files from an object.
+// UnlinkAllOf unlink all linked files from an object. If the bucket's
+// AutoGC option is set, files left with no remaining links are GC'd
+// immediately (subject to GC's safety window).
 func (b *Bucket) UnlinkAllOf(db DB, object string) error {
 	if object == "" {
 		return errEmptyObject
 	}
-	return b.unlink(db, object, "")
+	files, err := b.FilesOf(db, object)
+	if err != nil {
+		return err
+	}
+	if err := b.unlink(db, object, ""); err != nil {
+		return err
+	}
+	return b.maybeAutoGC(db, files)
 }
 
-// Unlink files from object.
+// Unlink files from object. If the bucket's AutoGC option is set, files
+// left with no remaining links are GC'd immediately (subject to GC's
+// safety window).
 func (b *Bucket) Unlink(db DB, object string, files ...string) error {
 	if object == "" {
 		return errEmptyObject
@@ -104,7 +115,23 @@ func (b *Bucket) Unlink(db DB, object string, files ...string) error {
 	if err := CheckHash(files...); err != nil {
 		return err
 	}
-	return b.unlink(db, object, filesCond(files, ""))
+	if err := b.unlink(db, object, filesCond(files, "")); err != nil {
+		return err
+	}
+	return b.maybeAutoGC(db, files)
+}
+
+// maybeAutoGC GC's any of files that are now orphaned and past GC's safety
+// window, if AutoGC is enabled. Going through autoGCHashes (rather than
+// gcHashes directly) matters: a file can be re-linked by a concurrent
+// in-flight upload in the instant its last link is removed, and the safety
+// window is what keeps that race from losing the blob out from under it.
+func (b *Bucket) maybeAutoGC(db DB, files []string) error {
+	if !b.AutoGC || len(files) == 0 {
+		return nil
+	}
+	_, err := b.autoGCHashes(db, files)
+	return err
 }
 
 func (b *Bucket) unlink(db DB, object string, conds string) error {
@@ -0,0 +1,179 @@
+package filestorage
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lovego/errs"
+)
+
+// sniffContentType determines a file's real content type from its own
+// bytes, falling back to the file name's extension only when the bytes are
+// not recognized. headerType, the client-supplied header, is never used as
+// the result, so upload validation (see MimeChecker, imageChecker) can't be
+// bypassed by a lying Content-Type header.
+func sniffContentType(f io.ReadSeeker, headerType, filename string) (string, error) {
+	buf := make([]byte, 512)
+	n, err := f.Read(buf)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sniffed := http.DetectContentType(buf[:n])
+	if sniffed != "application/octet-stream" {
+		return sniffed, nil
+	}
+	if ext := mime.TypeByExtension(filepath.Ext(filename)); ext != "" {
+		return strings.SplitN(ext, ";", 2)[0], nil
+	}
+	return sniffed, nil
+}
+
+// wrapFileCheck wraps fileCheck so it sees each file's sniffed
+// File.ContentType instead of whatever contentType createFileRecords
+// passes it internally, so validation (imageChecker, MimeChecker) can't be
+// bypassed by a lying Content-Type header. createFileRecords calls the
+// check once per file, in the same order as files. A nil fileCheck (Save
+// called with no validation) stays nil, rather than becoming a closure
+// that panics calling it.
+func wrapFileCheck(files []File, fileCheck func(string, int64) error) func(string, int64) error {
+	if fileCheck == nil {
+		return nil
+	}
+	i := 0
+	return func(_ string, size int64) error {
+		var contentType string
+		if i < len(files) {
+			contentType = files[i].ContentType
+		}
+		i++
+		return fileCheck(contentType, size)
+	}
+}
+
+// ensureFileMetadataColumns adds the content_type/original_name/size/
+// created_at columns FileInfo selects and GC's safety window filters on,
+// if they aren't there yet. created_at is included here too, not just
+// assumed pre-existing: FileInfo and GC's orphanedOlderThan both depend on
+// it, and without this a files table predating those features would make
+// FileInfo error and silently disable GC's safety window.
+func (b *Bucket) ensureFileMetadataColumns(db DB) error {
+	_, err := b.getDB(db).Exec(fmt.Sprintf(`
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS content_type text NOT NULL DEFAULT '';
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS original_name text NOT NULL DEFAULT '';
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS size bigint NOT NULL DEFAULT 0;
+	ALTER TABLE %s ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+	`, b.FilesTable, b.FilesTable, b.FilesTable, b.FilesTable,
+	))
+	return err
+}
+
+// recordFileMetadata persists each file's sniffed content type, original
+// name and size against its files-table row. createFileRecords already
+// inserted the row (hash, created_at); this fills in the columns it
+// doesn't know about.
+func (b *Bucket) recordFileMetadata(db DB, files []File, hashes []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	if err := b.ensureFileMetadataColumns(db); err != nil {
+		return err
+	}
+	for i := range files {
+		if i >= len(hashes) {
+			break
+		}
+		_, err := b.getDB(db).Exec(fmt.Sprintf(`
+		UPDATE %s SET content_type = %s, original_name = %s, size = %s WHERE hash = %s
+		`, b.FilesTable, quote(files[i].ContentType), quote(files[i].OriginalName),
+			strconv.FormatInt(files[i].Size, 10), quote(hashes[i]),
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileInfo describes a stored file's metadata.
+type FileInfo struct {
+	Hash         string
+	ContentType  string
+	Size         int64
+	OriginalName string
+	CreatedAt    time.Time
+}
+
+// FileInfo fetches metadata for a single stored file.
+func (b *Bucket) FileInfo(db DB, hash string) (FileInfo, error) {
+	if err := CheckHash(hash); err != nil {
+		return FileInfo{}, err
+	}
+	row := b.getDB(db).QueryRow(fmt.Sprintf(`
+	SELECT hash, content_type, size, original_name, created_at FROM %s WHERE hash = %s
+	`, b.FilesTable, quote(hash),
+	))
+	var info FileInfo
+	if err := row.Scan(&info.Hash, &info.ContentType, &info.Size, &info.OriginalName, &info.CreatedAt); err != nil {
+		return FileInfo{}, err
+	}
+	return info, nil
+}
+
+// FilesInfoOf fetches metadata for every file linked to object, in the same
+// order as FilesOf.
+func (b *Bucket) FilesInfoOf(db DB, object string) ([]FileInfo, error) {
+	rows, err := b.getDB(db).Query(fmt.Sprintf(`
+	SELECT f.hash, f.content_type, f.size, f.original_name, f.created_at
+	FROM %s f JOIN %s l ON l.file = f.hash
+	WHERE l.object = %s ORDER BY l.created_at
+	`, b.FilesTable, b.LinksTable, quote(object),
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var infos []FileInfo
+	for rows.Next() {
+		var info FileInfo
+		if err := rows.Scan(&info.Hash, &info.ContentType, &info.Size, &info.OriginalName, &info.CreatedAt); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// MimeChecker builds a fileCheck func that only allows content types in
+// Allowed and files no larger than MaxSize, the general case imageChecker
+// is a special case of.
+type MimeChecker struct {
+	Allowed []string
+	MaxSize int64
+}
+
+func (c MimeChecker) Check(contentType string, size int64) error {
+	var ok bool
+	for _, allowed := range c.Allowed {
+		if contentType == allowed {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return errs.Newf("args-err", "file type(%s) is not allowed.", contentType)
+	}
+	if c.MaxSize > 0 && size > c.MaxSize {
+		return errs.Newf("args-err", "file size(%d) cann't exceed %d.", size, c.MaxSize)
+	}
+	return nil
+}
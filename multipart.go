@@ -0,0 +1,361 @@
+package filestorage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lovego/errs"
+)
+
+var errUploadNotFound = errs.New("args-err", "multipart upload not found")
+var errPartsIncomplete = errs.New("args-err", "multipart upload has missing or out of order parts")
+var errPartsMismatch = errs.New("args-err", "multipart upload parts do not match the uploaded parts")
+
+// Part is one uploaded chunk of a multipart upload.
+type Part struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
+// pendingChecks holds each in-flight upload's fileCheck callback. Unlike
+// the part state in MultipartPartsTable, a callback can't be persisted to
+// the database, so it's lost across a process restart; CompleteMultipart
+// falls back to a no-op check in that case. Part etags/sizes/count,
+// however, are always validated against MultipartPartsTable regardless of
+// restarts, since that's the part of a forged/corrupted Complete request
+// that matters.
+var pendingChecks sync.Map // uploadID -> func(string, int64) error
+
+func (b *Bucket) createMultipartTable(db DB) error {
+	if b.MultipartTable == "" {
+		b.MultipartTable = "file_multipart_uploads"
+	}
+	if b.MultipartPartsTable == "" {
+		b.MultipartPartsTable = "file_multipart_parts"
+	}
+	_, err := b.getDB(db).Exec(fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		upload_id  text PRIMARY KEY,
+		object     text NOT NULL,
+		created_at timestamptz NOT NULL,
+		expires_at timestamptz NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS %s (
+		upload_id   text NOT NULL,
+		part_number int NOT NULL,
+		etag        text NOT NULL,
+		size        bigint NOT NULL,
+		created_at  timestamptz NOT NULL,
+		PRIMARY KEY (upload_id, part_number)
+	);
+	`, b.MultipartTable, b.MultipartPartsTable,
+	))
+	return err
+}
+
+// InitMultipart starts a new multipart upload for object, returning an
+// uploadID to pass to UploadPart/CompleteMultipart/AbortMultipart. Sessions
+// expire after 24h if never completed or aborted; see SweepMultipartUploads.
+func (b *Bucket) InitMultipart(db DB, object string, fileCheck func(string, int64) error) (string, error) {
+	if err := b.createMultipartTable(db); err != nil {
+		return "", err
+	}
+	uploadID, err := genUploadID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	_, err = b.getDB(db).Exec(fmt.Sprintf(`
+	INSERT INTO %s (upload_id, object, created_at, expires_at) VALUES (%s, %s, %s, %s)
+	`, b.MultipartTable, quote(uploadID), quote(object), fmtTime(now), fmtTime(now.Add(24*time.Hour)),
+	))
+	if err != nil {
+		return "", err
+	}
+	pendingChecks.Store(uploadID, fileCheck)
+	return uploadID, nil
+}
+
+// UploadPart stages one part of uploadID, returning its content hash as the
+// part's ETag.
+func (b *Bucket) UploadPart(db DB, uploadID string, partNumber int, r io.Reader, size int64) (string, error) {
+	return b.UploadPartWithOptions(db, uploadID, partNumber, r, size, nil)
+}
+
+// UploadPartWithOptions is UploadPart with an OnPartComplete callback; see
+// UploadOptions.
+func (b *Bucket) UploadPartWithOptions(
+	db DB, uploadID string, partNumber int, r io.Reader, size int64, opts *UploadOptions,
+) (string, error) {
+	if ok, err := b.multipartExists(db, uploadID); err != nil {
+		return "", err
+	} else if !ok {
+		return "", errUploadNotFound
+	}
+
+	dir := b.multipartDir(uploadID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	destPath := filepath.Join(dir, fmt.Sprintf("%06d", partNumber))
+	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer destFile.Close()
+
+	h := sha256.New()
+	written, err := io.Copy(destFile, io.TeeReader(r, h))
+	if err != nil {
+		return "", err
+	}
+	etag := hex.EncodeToString(h.Sum(nil))
+
+	_, err = b.getDB(db).Exec(fmt.Sprintf(`
+	INSERT INTO %s (upload_id, part_number, etag, size, created_at) VALUES (%s, %d, %s, %d, %s)
+	ON CONFLICT (upload_id, part_number) DO UPDATE SET etag = excluded.etag, size = excluded.size, created_at = excluded.created_at
+	`, b.MultipartPartsTable, quote(uploadID), partNumber, quote(etag), written, fmtTime(time.Now()),
+	))
+	if err != nil {
+		return "", err
+	}
+
+	if opts != nil && opts.OnPartComplete != nil {
+		opts.OnPartComplete(partNumber, etag)
+	}
+	return etag, nil
+}
+
+// multipartParts loads uploadID's persisted parts, ordered by number — the
+// source of truth for CompleteMultipart, since a caller's claimed parts
+// list can't be trusted on its own.
+func (b *Bucket) multipartParts(db DB, uploadID string) ([]Part, error) {
+	rows, err := b.getDB(db).Query(fmt.Sprintf(`
+	SELECT part_number, etag, size FROM %s WHERE upload_id = %s ORDER BY part_number
+	`, b.MultipartPartsTable, quote(uploadID),
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []Part
+	for rows.Next() {
+		var p Part
+		if err := rows.Scan(&p.Number, &p.ETag, &p.Size); err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// CompleteMultipart concatenates parts in order, hashes the result to
+// produce the final content hash, stores it via the bucket's files table
+// and links it to the upload's object, the same as Bucket.Save does. The
+// caller's parts must match what was actually staged by UploadPart, per
+// the session's persisted part state; CompleteMultipart never trusts
+// caller-supplied etags/sizes on their own.
+func (b *Bucket) CompleteMultipart(db DB, uploadID string, parts []Part) (hash string, err error) {
+	object, err := b.multipartObject(db, uploadID)
+	if err != nil {
+		return "", err
+	}
+	staged, err := b.multipartParts(db, uploadID)
+	if err != nil {
+		return "", err
+	}
+	for i := range staged {
+		if staged[i].Number != i+1 {
+			return "", errPartsIncomplete
+		}
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	if len(parts) != len(staged) {
+		return "", errPartsMismatch
+	}
+	for i := range parts {
+		if parts[i] != staged[i] {
+			return "", errPartsMismatch
+		}
+	}
+
+	readers := make([]io.ReadSeeker, len(staged))
+	var size int64
+	for i := range staged {
+		f, err := os.Open(filepath.Join(b.multipartDir(uploadID), fmt.Sprintf("%06d", staged[i].Number)))
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		readers[i] = f
+		size += staged[i].Size
+	}
+
+	fileCheck, _ := pendingChecks.Load(uploadID)
+	check, _ := fileCheck.(func(string, int64) error)
+	if check == nil {
+		check = func(string, int64) error { return nil }
+	}
+
+	hashes, err := b.Save(db, check, object, File{IO: &multiReadSeeker{readers: readers}, Size: size})
+	if err != nil {
+		return "", err
+	}
+	if err := b.abortMultipart(db, uploadID); err != nil {
+		return "", err
+	}
+	return hashes[0], nil
+}
+
+// AbortMultipart discards uploadID's staged parts and session record.
+func (b *Bucket) AbortMultipart(db DB, uploadID string) error {
+	return b.abortMultipart(db, uploadID)
+}
+
+func (b *Bucket) abortMultipart(db DB, uploadID string) error {
+	pendingChecks.Delete(uploadID)
+	if err := os.RemoveAll(b.multipartDir(uploadID)); err != nil {
+		return err
+	}
+	if _, err := b.getDB(db).Exec(fmt.Sprintf(
+		`DELETE FROM %s WHERE upload_id = %s`, b.MultipartPartsTable, quote(uploadID),
+	)); err != nil {
+		return err
+	}
+	_, err := b.getDB(db).Exec(fmt.Sprintf(
+		`DELETE FROM %s WHERE upload_id = %s`, b.MultipartTable, quote(uploadID),
+	))
+	return err
+}
+
+// SweepMultipartUploads aborts and removes multipart sessions that expired
+// without being completed, returning how many were swept.
+func (b *Bucket) SweepMultipartUploads(db DB) (int, error) {
+	if err := b.createMultipartTable(db); err != nil {
+		return 0, err
+	}
+	rows, err := b.getDB(db).Query(fmt.Sprintf(
+		`SELECT upload_id FROM %s WHERE expires_at < %s`, b.MultipartTable, fmtTime(time.Now()),
+	))
+	if err != nil {
+		return 0, err
+	}
+	var uploadIDs []string
+	for rows.Next() {
+		var uploadID string
+		if err := rows.Scan(&uploadID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		uploadIDs = append(uploadIDs, uploadID)
+	}
+	rows.Close()
+
+	for _, uploadID := range uploadIDs {
+		if err := b.abortMultipart(db, uploadID); err != nil {
+			return 0, err
+		}
+	}
+	return len(uploadIDs), nil
+}
+
+func (b *Bucket) multipartExists(db DB, uploadID string) (bool, error) {
+	row := b.getDB(db).QueryRow(fmt.Sprintf(
+		`SELECT true FROM %s WHERE upload_id = %s`, b.MultipartTable, quote(uploadID),
+	))
+	var exists bool
+	if err := row.Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return exists, nil
+}
+
+func (b *Bucket) multipartObject(db DB, uploadID string) (string, error) {
+	row := b.getDB(db).QueryRow(fmt.Sprintf(
+		`SELECT object FROM %s WHERE upload_id = %s`, b.MultipartTable, quote(uploadID),
+	))
+	var object string
+	if err := row.Scan(&object); err != nil {
+		if err == sql.ErrNoRows {
+			return "", errUploadNotFound
+		}
+		return "", err
+	}
+	return object, nil
+}
+
+// multipartDir returns the local directory uploadID's parts are staged
+// under: b.MultipartStageDir if set, else os.TempDir(). Staging is always
+// local disk, even when Backend is an object store — so UploadPart and
+// CompleteMultipart for a given uploadID must run on the same host, and a
+// configured MultipartStageDir (a mounted/shared volume, not the default
+// temp dir) only helps that host's own restarts survive, not a failover
+// to a different one.
+func (b *Bucket) multipartDir(uploadID string) string {
+	dir := b.MultipartStageDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "fs_multipart_"+uploadID)
+}
+
+func genUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// multiReadSeeker concatenates readers in order. It only supports
+// rewinding to the start (not arbitrary seeks), which is what this
+// package's hash-then-store upload path needs: createFileRecords reads it
+// once to EOF to compute the hash, then saveFile reads it again from the
+// start to store it.
+type multiReadSeeker struct {
+	readers []io.ReadSeeker
+	index   int
+}
+
+func (m *multiReadSeeker) Read(p []byte) (int, error) {
+	for m.index < len(m.readers) {
+		n, err := m.readers[m.index].Read(p)
+		if err == io.EOF {
+			m.index++
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
+
+func (m *multiReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, errs.New("args-err", "multiReadSeeker only supports rewinding to the start")
+	}
+	for _, r := range m.readers {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	m.index = 0
+	return 0, nil
+}
@@ -0,0 +1,53 @@
+package filestorage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// TestMultiReadSeekerRewind covers the bug where Seek(0, SeekStart) reset
+// only the in-memory index but never rewound the underlying readers: after
+// a first full read (as createFileRecords does to hash the content), a
+// second read (as saveFile does to store it) returned 0 bytes instead of
+// the content again.
+func TestMultiReadSeekerRewind(t *testing.T) {
+	parts := []string{"hello, ", "world", "!"}
+	readers := make([]io.ReadSeeker, len(parts))
+	for i, p := range parts {
+		readers[i] = strings.NewReader(p)
+	}
+	m := &multiReadSeeker{readers: readers}
+
+	first, err := ioutil.ReadAll(m)
+	if err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	if got, want := string(first), "hello, world!"; got != want {
+		t.Fatalf("first read = %q, want %q", got, want)
+	}
+
+	if _, err := m.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("seek: %v", err)
+	}
+
+	second, err := ioutil.ReadAll(m)
+	if err != nil {
+		t.Fatalf("second read: %v", err)
+	}
+	if !bytes.Equal(second, first) {
+		t.Fatalf("second read = %q, want %q (rewind did not reach the underlying readers)", second, first)
+	}
+}
+
+func TestMultiReadSeekerSeekRejectsArbitraryOffsets(t *testing.T) {
+	m := &multiReadSeeker{readers: []io.ReadSeeker{strings.NewReader("abc")}}
+	if _, err := m.Seek(1, io.SeekStart); err == nil {
+		t.Fatal("expected an error seeking to a non-zero offset")
+	}
+	if _, err := m.Seek(0, io.SeekEnd); err == nil {
+		t.Fatal("expected an error seeking with a non-start whence")
+	}
+}
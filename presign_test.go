@@ -0,0 +1,153 @@
+package filestorage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPresignRoundTrip(t *testing.T) {
+	b := &Bucket{SecretKey: "test-secret"}
+
+	url, err := b.PresignPut("deadbeef", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+	if !strings.HasPrefix(url, "/deadbeef?expires=") {
+		t.Fatalf("PresignPut url = %q, want a /<hash>?expires=...&sig=... url", url)
+	}
+
+	called := false
+	handler := b.VerifySignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPut, url, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid signature: status = %d, want 200", rec.Code)
+	}
+	if !called {
+		t.Fatal("valid signature: next handler was not called")
+	}
+}
+
+func TestVerifySignatureRejectsTamperedSignature(t *testing.T) {
+	b := &Bucket{SecretKey: "test-secret"}
+	url, err := b.PresignPut("deadbeef", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+
+	handler := b.VerifySignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not run for a tampered signature")
+	}))
+
+	// Flip the path after signing, so the canonical string no longer
+	// matches the signature.
+	tampered := strings.Replace(url, "/deadbeef", "/abad1dea", 1)
+	req := httptest.NewRequest(http.MethodPut, tampered, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("tampered signature: status = %d, want 403", rec.Code)
+	}
+}
+
+func TestVerifySignatureRejectsExpiredSignature(t *testing.T) {
+	b := &Bucket{SecretKey: "test-secret"}
+	url, err := b.PresignPut("deadbeef", -time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+
+	handler := b.VerifySignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not run for an expired signature")
+	}))
+
+	req := httptest.NewRequest(http.MethodPut, url, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expired signature: status = %d, want 403", rec.Code)
+	}
+}
+
+func TestVerifySignatureRejectsAppendedLinkObject(t *testing.T) {
+	b := &Bucket{SecretKey: "test-secret"}
+	url, err := b.PresignPut("deadbeef", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+
+	handler := b.VerifySignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not run once linkObject is appended unsigned")
+	}))
+
+	// PresignPut signed an empty linkObject; appending one to the query
+	// string must not pass verification, or a URL issued for a bare
+	// upload could be redirected to link to an arbitrary object.
+	req := httptest.NewRequest(http.MethodPut, url+"&linkObject=someone-elses-object", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("appended linkObject: status = %d, want 403", rec.Code)
+	}
+}
+
+func TestPresignPutWithLinkBindsLinkObject(t *testing.T) {
+	b := &Bucket{SecretKey: "test-secret"}
+	url, err := b.PresignPutWithLink("deadbeef", "object-1", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPutWithLink: %v", err)
+	}
+	if !strings.Contains(url, "linkObject=object-1") {
+		t.Fatalf("PresignPutWithLink url = %q, want it to contain linkObject=object-1", url)
+	}
+
+	called := false
+	handler := b.VerifySignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	req := httptest.NewRequest(http.MethodPut, url, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !called {
+		t.Fatalf("valid linkObject: status = %d, called = %v, want 200/true", rec.Code, called)
+	}
+
+	tampered := strings.Replace(url, "object-1", "object-2", 1)
+	handler = b.VerifySignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not run once linkObject is changed")
+	}))
+	req = httptest.NewRequest(http.MethodPut, tampered, nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("tampered linkObject: status = %d, want 403", rec.Code)
+	}
+}
+
+func TestVerifySignatureRejectsDifferentSecret(t *testing.T) {
+	signer := &Bucket{SecretKey: "signer-secret"}
+	verifier := &Bucket{SecretKey: "verifier-secret"}
+
+	url, err := signer.PresignPut("deadbeef", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+
+	handler := verifier.VerifySignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("next handler should not run when secrets differ")
+	}))
+
+	req := httptest.NewRequest(http.MethodPut, url, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("mismatched secret: status = %d, want 403", rec.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package filestorage
+
+import "io"
+
+// UploadOptions carries optional progress callbacks for Save/Upload and
+// UploadPart. Callers wiring an HTTP endpoint can use OnProgress to stream
+// progress over SSE/websocket; CLI tools can plug in a pb-style bar.
+type UploadOptions struct {
+	// OnProgress is called as each file's content is written to the
+	// backend, with the file's hash (already known by then) and how many
+	// of its total bytes have been written so far.
+	OnProgress func(hash string, bytesWritten, totalBytes int64)
+	// OnPartComplete is called after a multipart part finishes uploading.
+	OnPartComplete func(part int, hash string)
+}
+
+// progressReader wraps a reader, reporting bytesWritten/totalBytes to
+// onProgress as it's read. It's deliberately a thin TeeReader-style
+// wrapper so it stays correct regardless of whether the underlying reader
+// is re-read after being hashed, the way File.IO is used elsewhere in this
+// package.
+type progressReader struct {
+	io.Reader
+	hash       string
+	total      int64
+	written    int64
+	onProgress func(hash string, bytesWritten, totalBytes int64)
+}
+
+func withProgress(r io.Reader, hash string, total int64, onProgress func(string, int64, int64)) io.Reader {
+	if onProgress == nil {
+		return r
+	}
+	return &progressReader{Reader: r, hash: hash, total: total, onProgress: onProgress}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.written += int64(n)
+		r.onProgress(r.hash, r.written, r.total)
+	}
+	return n, err
+}
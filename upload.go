@@ -4,15 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"io"
-	"io/ioutil"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
-	"github.com/lovego/addrs"
 	"github.com/lovego/errs"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
@@ -38,6 +34,14 @@ func UploadImages(req *http.Request, lang string) ([]string, error) {
 // Upload files, if object is not empty, the files are linked to it.
 func (b *Bucket) Upload(
 	db DB, fileCheck func(string, int64) error, object string, fileHeaders ...*multipart.FileHeader,
+) ([]string, error) {
+	return b.UploadWithOptions(db, fileCheck, object, nil, fileHeaders...)
+}
+
+// UploadWithOptions is Upload with progress callbacks; see UploadOptions.
+func (b *Bucket) UploadWithOptions(
+	db DB, fileCheck func(string, int64) error, object string, opts *UploadOptions,
+	fileHeaders ...*multipart.FileHeader,
 ) ([]string, error) {
 	var files = make([]File, len(fileHeaders))
 	for i := range fileHeaders {
@@ -46,27 +50,45 @@ func (b *Bucket) Upload(
 			return nil, err
 		}
 		defer f.Close()
+		contentType, err := sniffContentType(f, fileHeaders[i].Header.Get("Content-Type"), fileHeaders[i].Filename)
+		if err != nil {
+			return nil, err
+		}
 		files[i].IO = f
 		files[i].Size = fileHeaders[i].Size
+		files[i].ContentType = contentType
+		files[i].OriginalName = fileHeaders[i].Filename
 	}
-	return b.Save(db, fileCheck, object, files...)
+	return b.SaveWithOptions(db, fileCheck, object, opts, files...)
 }
 
 // File reprents the file to store.
 type File struct {
 	IO   io.ReadSeeker
 	Size int64
+	// ContentType is sniffed from the file's own bytes (see
+	// sniffContentType), not taken on faith from a client-supplied header.
+	ContentType string
+	// OriginalName is the client-supplied file name, kept only as metadata.
+	OriginalName string
 }
 
 // Save file into storage.
 func (b *Bucket) Save(
 	db DB, fileCheck func(string, int64) error, object string, files ...File,
+) ([]string, error) {
+	return b.SaveWithOptions(db, fileCheck, object, nil, files...)
+}
+
+// SaveWithOptions is Save with progress callbacks; see UploadOptions.
+func (b *Bucket) SaveWithOptions(
+	db DB, fileCheck func(string, int64) error, object string, opts *UploadOptions, files ...File,
 ) (fileHashes []string, err error) {
 	if len(files) == 0 {
 		return nil, nil
 	}
 	err = runInTx(db, func(tx DB) error {
-		hashes, err := b.save(tx, fileCheck, object, files)
+		hashes, err := b.save(tx, fileCheck, object, files, opts)
 		if err != nil {
 			return err
 		}
@@ -77,9 +99,18 @@ func (b *Bucket) Save(
 }
 
 func (b *Bucket) save(
-	db DB, fileCheck func(string, int64) error, object string, files []File,
+	db DB, fileCheck func(string, int64) error, object string, files []File, opts *UploadOptions,
 ) ([]string, error) {
-	records, err := b.createFileRecords(db, files, fileCheck)
+	for i := range files {
+		if files[i].ContentType == "" {
+			contentType, err := sniffContentType(files[i].IO, "", files[i].OriginalName)
+			if err != nil {
+				return nil, err
+			}
+			files[i].ContentType = contentType
+		}
+	}
+	records, err := b.createFileRecords(db, files, wrapFileCheck(files, fileCheck))
 	if err != nil {
 		return nil, err
 	}
@@ -87,94 +118,35 @@ func (b *Bucket) save(
 	for i := range records {
 		hashes = append(hashes, records[i].Hash)
 	}
+	if err := b.recordFileMetadata(db, files, hashes); err != nil {
+		return nil, err
+	}
 	if object != "" {
 		if err := b.Link(db, object, hashes...); err != nil {
 			return nil, err
 		}
 	}
+	var onProgress func(string, int64, int64)
+	if opts != nil {
+		onProgress = opts.OnProgress
+	}
 	for i := range records {
-		if err := b.saveFile(records[i].File, records[i].Hash); err != nil {
+		if err := b.saveFile(records[i].File, records[i].Size, records[i].Hash, onProgress); err != nil {
 			return nil, err
 		}
 	}
 	return hashes, nil
 }
 
-func (b *Bucket) saveFile(file io.Reader, hash string) error {
-	var srcPath string
-	var destPath = filepath.Join(b.Dir, b.FilePath(hash))
-	if b.localMachine {
-		if err := b.writeFile(file, destPath); err != nil {
-			return err
-		}
-		srcPath = destPath
-	} else {
-		tempFile, err := b.writeTempFile(file)
-		if err != nil {
-			return err
-		}
-		srcPath = tempFile
-	}
-	for _, addr := range b.otherMachines {
-		if err := exec.Command("scp", srcPath, addr+":"+destPath).Run(); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (b *Bucket) writeFile(file io.Reader, destPath string) error {
-	if _, err := os.Stat(destPath); err == nil {
-		return nil
-	} else if !os.IsNotExist(err) {
-		return err
-	}
-
-	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-		return err
-	}
-	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+func (b *Bucket) saveFile(file io.Reader, size int64, hash string, onProgress func(string, int64, int64)) error {
+	backend, err := b.backend()
 	if err != nil {
-		if os.IsExist(err) {
-			return nil
-		}
 		return err
 	}
-	defer destFile.Close()
-	_, err = io.Copy(destFile, file)
-	return err
-}
-
-func (b *Bucket) writeTempFile(file io.Reader) (string, error) {
-	temp, err := ioutil.TempFile("", "fs_")
-	if err != nil {
-		return "", err
-	}
-	defer temp.Close()
-	if _, err := io.Copy(temp, file); err != nil {
-		return "", err
-	}
-	return temp.Name(), nil
-}
-
-func (b *Bucket) parseMachines() error {
-	var user string
-	if b.ScpUser != "" {
-		user = b.ScpUser + "@"
-	}
-	for _, addr := range b.Machines {
-		if ok, err := addrs.IsLocalhost(addr); err != nil {
-			return err
-		} else if ok {
-			b.localMachine = true
-		} else {
-			b.otherMachines = append(b.otherMachines, user+addr)
-		}
-	}
-	return nil
+	return backend.Put(context.Background(), b.FilePath(hash), withProgress(file, hash, size, onProgress), size)
 }
 
-// FilePath returns the file path to store on disk.
+// FilePath returns the backend key to store a file's content under.
 func (b *Bucket) FilePath(hash string) string {
 	var path string
 	var i uint8
@@ -209,6 +181,9 @@ type imageChecker struct {
 	lang string
 }
 
+// Check is called with each file's sniffed content type (see
+// sniffContentType/wrapFileCheck), not a client-supplied header, so a
+// lying Content-Type can't get a non-image file past this check.
 func (img imageChecker) Check(contentType string, size int64) error {
 	if !strings.HasPrefix(contentType, "image/") {
 		return img.fileTypeError(contentType)